@@ -0,0 +1,86 @@
+package signalr
+
+import "time"
+
+// startKeepAlive arms the read deadline/pong handler for the current
+// WebSocket connection, if any, and (re)starts the ping ticker goroutine.
+// Called with c.mutex held, from Start.
+func (c *Client) startKeepAlive() {
+	if c.pingStop != nil {
+		close(c.pingStop)
+	}
+	stop := make(chan struct{})
+	c.pingStop = stop
+
+	c.touch()
+
+	if ws, ok := c.transport.(*wsTransport); ok {
+		ws.conn.SetReadDeadline(time.Now().Add(c.pongWait))
+		ws.conn.SetPongHandler(func(string) error {
+			c.touch()
+			return ws.conn.SetReadDeadline(time.Now().Add(c.pongWait))
+		})
+	}
+
+	go c.pingLoop(c.transport, stop)
+}
+
+// pingLoop emits a SignalR Ping message every c.pingPeriod until stop is
+// closed, closing the transport if a ping fails to write. Writes go through
+// writeMutex, shared with writeFrame/sendHandshake, since gorilla/websocket
+// allows only one writer on a connection at a time.
+func (c *Client) pingLoop(t Transport, stop chan struct{}) {
+	ticker := time.NewTicker(c.pingPeriod)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-stop:
+			return
+		case <-ticker.C:
+			data, err := c.protocol.Encode(PingMessage{Type: Ping})
+			if err != nil {
+				continue
+			}
+
+			if err := c.writePing(t, data); err != nil {
+				c.logf("signalr: ping failed, closing connection: %v", err)
+				t.Close()
+				return
+			}
+		}
+	}
+}
+
+// writePing writes a single ping frame with writeMutex held.
+func (c *Client) writePing(t Transport, data []byte) error {
+	c.writeMutex.Lock()
+	defer c.writeMutex.Unlock()
+
+	if ws, ok := t.(*wsTransport); ok {
+		ws.conn.SetWriteDeadline(time.Now().Add(c.writeWait))
+		if c.compressionEnabled {
+			ws.conn.EnableWriteCompression(len(data) >= c.compressionThreshold)
+		}
+	}
+
+	return t.Send(data)
+}
+
+// touch records that the server was just observed to be alive, either
+// through a transport-level pong, a SignalR Ping frame, or any other
+// message.
+func (c *Client) touch() {
+	c.lastActive.Store(time.Now().UnixNano())
+}
+
+// LastActive returns the last time the client observed activity from the
+// server.
+func (c *Client) LastActive() time.Time {
+	n := c.lastActive.Load()
+	if n == 0 {
+		return time.Time{}
+	}
+
+	return time.Unix(0, n)
+}
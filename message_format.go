@@ -1,24 +1,57 @@
 package signalr
 
 import (
-	"fmt"
+	"encoding/json"
+
+	"github.com/gorilla/websocket"
 )
 
 const recordSeparatorCode = 0x1e
 
-var mFormat MessageFormat
+// JSONProtocol implements Protocol using the JSON Hub Protocol: each message
+// is a JSON payload followed by the ASCII record separator (0x1E), sent as a
+// websocket text frame.
+type JSONProtocol struct{}
+
+// Name ...
+func (f *JSONProtocol) Name() string { return "json" }
+
+// FrameType ...
+func (f *JSONProtocol) FrameType() int { return websocket.TextMessage }
+
+// Encode ...
+func (f *JSONProtocol) Encode(msg interface{}) ([]byte, error) {
+	data, err := toJSON(msg)
+	if err != nil {
+		return nil, err
+	}
 
-func init() {
-	mFormat = MessageFormat{}
+	return append(data, recordSeparatorCode), nil
 }
 
-// MessageFormat ...
-type MessageFormat struct{}
+// Decode ...
+func (f *JSONProtocol) Decode(frame []byte) (msgType int, payload []byte, err error) {
+	if len(frame) == 0 {
+		return 0, nil, nil
+	}
 
-func (f *MessageFormat) write(m string) string {
-	return fmt.Sprintf("%s%s", m, string(recordSeparatorCode))
+	payload = frame[:len(frame)-1]
+
+	var probe struct {
+		Type int `json:"type"`
+	}
+	if err = json.Unmarshal(payload, &probe); err != nil {
+		return 0, nil, err
+	}
+
+	return probe.Type, payload, nil
 }
 
-func (f *MessageFormat) parse(m []byte) []byte {
-	return m[:len(m)-1]
+// toJSON marshals msg unless it is already a marshaled []byte payload.
+func toJSON(msg interface{}) ([]byte, error) {
+	if b, ok := msg.([]byte); ok {
+		return b, nil
+	}
+
+	return json.Marshal(msg)
 }
@@ -0,0 +1,150 @@
+package signalr
+
+import (
+	"net/http"
+	"time"
+
+	"github.com/gorilla/websocket"
+)
+
+// Logger is the subset of *log.Logger used by Client for diagnostic output.
+type Logger interface {
+	Printf(format string, v ...interface{})
+}
+
+// Option configures a Client constructed with NewClient.
+type Option func(*Client)
+
+// WithProtocol selects the Hub Protocol used for every message exchanged
+// after the handshake. Defaults to JSONProtocol. Equivalent to setting
+// NegotiationRequestPayload.Protocol.
+func WithProtocol(p Protocol) Option {
+	return func(c *Client) {
+		c.protocol = p
+	}
+}
+
+// WithDialer overrides the *websocket.Dialer used to establish the
+// websocket connection. Defaults to websocket.DefaultDialer.
+func WithDialer(d *websocket.Dialer) Option {
+	return func(c *Client) {
+		c.dialer = d
+	}
+}
+
+// WithHTTPClient overrides the *http.Client used for the negotiate request.
+// Defaults to http.DefaultClient.
+func WithHTTPClient(hc *http.Client) Option {
+	return func(c *Client) {
+		c.httpClient = hc
+	}
+}
+
+// WithLogger attaches a Logger the Client uses for diagnostic output.
+// Disabled by default.
+func WithLogger(l Logger) Option {
+	return func(c *Client) {
+		c.logger = l
+	}
+}
+
+// WithHandler registers the callback invoked for every incoming Invocation
+// message. It is used by Read whenever Read is called with a nil handler.
+func WithHandler(f func(InvokeMessage)) Option {
+	return func(c *Client) {
+		c.handler = f
+	}
+}
+
+// WithReconnectPolicy enables automatic reconnect. policy is called before
+// each attempt with the 1-based attempt number and returns how long to wait
+// before that attempt; it returns ok = false to give up permanently.
+// Without this option the client never reconnects on its own.
+func WithReconnectPolicy(policy func(attempt int) (delay time.Duration, ok bool)) Option {
+	return func(c *Client) {
+		c.reconnectPolicy = policy
+	}
+}
+
+// WithReplayPendingOnReconnect makes a successful automatic reconnect
+// re-send the InvokeMessage/StreamInvocation of every call still awaiting a
+// Completion. Off by default: since the client cannot tell whether the
+// original invocation already ran on the server before the connection
+// dropped, replaying it can invoke a non-idempotent hub method (e.g.
+// "SendMessage") twice. Only enable this for hub methods that are safe to
+// call at-least-once.
+func WithReplayPendingOnReconnect() Option {
+	return func(c *Client) {
+		c.replayPendingOnReconnect = true
+	}
+}
+
+// WithOnReconnecting registers a callback invoked when the client starts
+// attempting to reconnect after losing its connection. cause is the error or
+// Close message that triggered the reconnect.
+func WithOnReconnecting(f func(cause error)) Option {
+	return func(c *Client) {
+		c.onReconnecting = f
+	}
+}
+
+// WithOnReconnected registers a callback invoked once an automatic
+// reconnect succeeds.
+func WithOnReconnected(f func()) Option {
+	return func(c *Client) {
+		c.onReconnected = f
+	}
+}
+
+// WithOnClose registers a callback invoked when the connection is closed for
+// good: Stop was called, the server sent a Close message that does not allow
+// reconnect, or the connection was lost and either no ReconnectPolicy is set
+// or reconnect gave up. cause is nil for a clean Stop.
+func WithOnClose(f func(cause error)) Option {
+	return func(c *Client) {
+		c.onClose = f
+	}
+}
+
+// WithPingPeriod overrides how often the client sends a SignalR Ping
+// message to the server. Defaults to pingPeriod.
+func WithPingPeriod(d time.Duration) Option {
+	return func(c *Client) {
+		c.pingPeriod = d
+	}
+}
+
+// WithPongWait overrides how long the client waits, on the WebSocket
+// transport, for a pong or a SignalR Ping message from the server before its
+// read deadline expires and the connection is considered dead. Other
+// messages do not extend the deadline. Defaults to pongWait.
+func WithPongWait(d time.Duration) Option {
+	return func(c *Client) {
+		c.pongWait = d
+	}
+}
+
+// WithWriteWait overrides how long a write, including keep-alive pings, may
+// take before it is considered failed. Defaults to waitWait.
+func WithWriteWait(d time.Duration) Option {
+	return func(c *Client) {
+		c.writeWait = d
+	}
+}
+
+// WithCompression enables permessage-deflate compression (RFC 7692) on the
+// websocket transport. level is passed to Conn.SetCompressionLevel (see
+// compress/flate for valid values). Messages smaller than threshold bytes
+// are sent uncompressed, since compression overhead outweighs the savings
+// for tiny payloads such as handshake and ping frames.
+func WithCompression(level, threshold int) Option {
+	return func(c *Client) {
+		dialer := *c.dialer
+		dialer.EnableCompression = true
+		c.dialer = &dialer
+
+		c.compressionEnabled = true
+		c.compressionLevel = level
+		c.compressionThreshold = threshold
+	}
+}
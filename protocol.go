@@ -0,0 +1,28 @@
+package signalr
+
+// Protocol encodes messages into, and decodes messages out of, the wire
+// format exchanged with a SignalR hub. The client and server agree on a
+// Protocol during the handshake (see HandshakeRequestMessage.Protocol) and
+// use it for every message exchanged afterwards.
+type Protocol interface {
+	// Name is the protocol name advertised in the HandshakeRequestMessage,
+	// e.g. "json" or "messagepack".
+	Name() string
+
+	// FrameType is the gorilla/websocket frame type (TextMessage or
+	// BinaryMessage) that Encode's output must be sent as.
+	FrameType() int
+
+	// Encode frames msg for transmission over the wire. msg is either a
+	// struct to be marshaled or a []byte containing an already-marshaled
+	// payload.
+	Encode(msg interface{}) ([]byte, error)
+
+	// Decode extracts the SignalR message type and the message payload from
+	// a single frame read off the wire. payload is always normalized to the
+	// JSON representation of the corresponding *Message struct (InvokeMessage,
+	// CompletionMessage, ...), regardless of the protocol's wire format, so
+	// callers can decode it with encoding/json without knowing which Protocol
+	// produced it.
+	Decode(frame []byte) (msgType int, payload []byte, err error)
+}
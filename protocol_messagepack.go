@@ -0,0 +1,244 @@
+package signalr
+
+import (
+	"encoding/binary"
+	"encoding/json"
+	"fmt"
+
+	"github.com/gorilla/websocket"
+	"github.com/vmihailenco/msgpack/v5"
+)
+
+// MessagePackProtocol implements Protocol using the MessagePack Hub
+// Protocol: each message is a msgpack-encoded positional array (not a map —
+// see msgpackEncodeFields) whose first element is the message type,
+// prefixed with its length encoded as a base-128 varint, sent as a
+// websocket binary frame.
+type MessagePackProtocol struct{}
+
+// Name ...
+func (f *MessagePackProtocol) Name() string { return "messagepack" }
+
+// FrameType ...
+func (f *MessagePackProtocol) FrameType() int { return websocket.BinaryMessage }
+
+// Encode ...
+func (f *MessagePackProtocol) Encode(msg interface{}) ([]byte, error) {
+	data, ok := msg.([]byte)
+	if !ok {
+		var err error
+		data, err = msgpackEncodeFields(msg)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	prefix := make([]byte, binary.MaxVarintLen64)
+	n := binary.PutUvarint(prefix, uint64(len(data)))
+
+	return append(prefix[:n], data...), nil
+}
+
+// msgpackEncodeFields builds the positional array the MessagePack Hub
+// Protocol requires for msg, e.g. [type, headers, invocationId, target,
+// arguments, streamIds] for an Invocation. A generic msgpack.Marshal of the
+// Go struct would instead produce a map keyed by field name, which neither
+// a real SignalR server nor Decode, below, can read back.
+func msgpackEncodeFields(msg interface{}) ([]byte, error) {
+	headers := map[string]string{}
+
+	switch m := msg.(type) {
+	case InvokeMessage:
+		return msgpack.Marshal([]interface{}{m.Type, headers, m.InvocationID, m.Target, m.Arguments, nil})
+
+	case StreamItemMessage:
+		item, err := unmarshalRaw(m.Item)
+		if err != nil {
+			return nil, err
+		}
+		return msgpack.Marshal([]interface{}{m.Type, headers, m.InvocationID, item})
+
+	case CompletionMessage:
+		switch {
+		case m.Error != "":
+			return msgpack.Marshal([]interface{}{m.Type, headers, m.InvocationID, completionResultError, m.Error})
+		case len(m.Result) > 0:
+			result, err := unmarshalRaw(m.Result)
+			if err != nil {
+				return nil, err
+			}
+			return msgpack.Marshal([]interface{}{m.Type, headers, m.InvocationID, completionResultNonVoid, result})
+		default:
+			return msgpack.Marshal([]interface{}{m.Type, headers, m.InvocationID, completionResultVoid})
+		}
+
+	case CancelInvocationMessage:
+		return msgpack.Marshal([]interface{}{m.Type, headers, m.InvocationID})
+
+	case PingMessage:
+		return msgpack.Marshal([]interface{}{m.Type})
+
+	case CloseMessage:
+		return msgpack.Marshal([]interface{}{m.Type, m.Error, m.AllowReconnect})
+
+	default:
+		return nil, fmt.Errorf("messagepack: unsupported message type %T", msg)
+	}
+}
+
+// completionResultKind values per the MessagePack Hub Protocol spec's
+// Completion message: the 4th array element says how to read the 5th.
+const (
+	completionResultError = iota + 1
+	completionResultVoid
+	completionResultNonVoid
+)
+
+// unmarshalRaw decodes a json.RawMessage back into a plain Go value suitable
+// for msgpack.Marshal. raw is nil for an absent/omitted field.
+func unmarshalRaw(raw json.RawMessage) (interface{}, error) {
+	if len(raw) == 0 {
+		return nil, nil
+	}
+
+	var v interface{}
+	if err := json.Unmarshal(raw, &v); err != nil {
+		return nil, err
+	}
+
+	return v, nil
+}
+
+// Decode ...
+func (f *MessagePackProtocol) Decode(frame []byte) (msgType int, payload []byte, err error) {
+	length, n := binary.Uvarint(frame)
+	if n <= 0 {
+		return 0, nil, fmt.Errorf("messagepack: invalid message length prefix")
+	}
+
+	body := frame[n:]
+	if uint64(len(body)) < length {
+		return 0, nil, fmt.Errorf("messagepack: truncated message, want %d bytes, got %d", length, len(body))
+	}
+	body = body[:length]
+
+	var fields []interface{}
+	if err = msgpack.Unmarshal(body, &fields); err != nil {
+		return 0, nil, err
+	}
+
+	if len(fields) == 0 {
+		return 0, nil, fmt.Errorf("messagepack: message array is empty")
+	}
+
+	t, ok := msgpackInt(fields[0])
+	if !ok {
+		return 0, nil, fmt.Errorf("messagepack: unexpected message type field %T", fields[0])
+	}
+
+	payload, err = msgpackFieldsToJSON(t, fields)
+	if err != nil {
+		return 0, nil, err
+	}
+
+	return t, payload, nil
+}
+
+// msgpackFieldsToJSON converts the positional array fields (as decoded by
+// Decode, above) for message type t into the same JSON representation
+// JSONProtocol.Decode would have produced for an equivalent frame, so the
+// rest of Client can read payload with encoding/json regardless of which
+// Protocol is in use.
+func msgpackFieldsToJSON(t int, fields []interface{}) ([]byte, error) {
+	field := func(i int) interface{} {
+		if i < len(fields) {
+			return fields[i]
+		}
+		return nil
+	}
+	str := func(i int) string {
+		s, _ := field(i).(string)
+		return s
+	}
+
+	switch t {
+	case Invocation, StreamInvocation:
+		args, _ := field(4).([]interface{})
+		return json.Marshal(InvokeMessage{
+			Type:         t,
+			InvocationID: str(2),
+			Target:       str(3),
+			Arguments:    args,
+		})
+
+	case StreamItem:
+		item, err := json.Marshal(field(3))
+		if err != nil {
+			return nil, err
+		}
+		return json.Marshal(StreamItemMessage{
+			Type:         t,
+			InvocationID: str(2),
+			Item:         item,
+		})
+
+	case Completion:
+		cm := CompletionMessage{Type: t, InvocationID: str(2)}
+		resultKind, _ := msgpackInt(field(3))
+
+		switch resultKind {
+		case completionResultError:
+			cm.Error = str(4)
+		case completionResultNonVoid:
+			result, err := json.Marshal(field(4))
+			if err != nil {
+				return nil, err
+			}
+			cm.Result = result
+		}
+
+		return json.Marshal(cm)
+
+	case CancelInvocation:
+		return json.Marshal(CancelInvocationMessage{Type: t, InvocationID: str(2)})
+
+	case Ping:
+		return json.Marshal(PingMessage{Type: t})
+
+	case Close:
+		allowReconnect, _ := field(2).(bool)
+		return json.Marshal(CloseMessage{Type: t, Error: str(1), AllowReconnect: allowReconnect})
+
+	default:
+		return json.Marshal(struct {
+			Type int `json:"type"`
+		}{Type: t})
+	}
+}
+
+// msgpackInt normalizes any of the integer types msgpack.Unmarshal may
+// produce for a given value (it picks the smallest type that fits) to int.
+func msgpackInt(v interface{}) (int, bool) {
+	switch n := v.(type) {
+	case int8:
+		return int(n), true
+	case int16:
+		return int(n), true
+	case int32:
+		return int(n), true
+	case int64:
+		return int(n), true
+	case uint8:
+		return int(n), true
+	case uint16:
+		return int(n), true
+	case uint32:
+		return int(n), true
+	case uint64:
+		return int(n), true
+	case int:
+		return n, true
+	}
+
+	return 0, false
+}
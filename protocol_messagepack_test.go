@@ -0,0 +1,70 @@
+package signalr
+
+import (
+	"encoding/json"
+	"testing"
+)
+
+func TestMessagePackProtocolRoundTrip(t *testing.T) {
+	p := &MessagePackProtocol{}
+
+	frame, err := p.Encode(InvokeMessage{
+		Type:         Invocation,
+		InvocationID: "42",
+		Target:       "Echo",
+		Arguments:    []interface{}{"hi"},
+	})
+	if err != nil {
+		t.Fatalf("Encode: %v", err)
+	}
+
+	msgType, payload, err := p.Decode(frame)
+	if err != nil {
+		t.Fatalf("Decode: %v", err)
+	}
+	if msgType != Invocation {
+		t.Fatalf("msgType = %d, want %d", msgType, Invocation)
+	}
+
+	var got InvokeMessage
+	if err := json.Unmarshal(payload, &got); err != nil {
+		t.Fatalf("Decode payload does not unmarshal into InvokeMessage: %v", err)
+	}
+	if got.InvocationID != "42" || got.Target != "Echo" || len(got.Arguments) != 1 || got.Arguments[0] != "hi" {
+		t.Fatalf("unexpected round-tripped message: %+v", got)
+	}
+}
+
+func TestMessagePackProtocolDecodeCompletion(t *testing.T) {
+	p := &MessagePackProtocol{}
+
+	frame, err := p.Encode(CompletionMessage{
+		Type:         Completion,
+		InvocationID: "7",
+		Result:       json.RawMessage(`{"n":1}`),
+	})
+	if err != nil {
+		t.Fatalf("Encode: %v", err)
+	}
+
+	msgType, payload, err := p.Decode(frame)
+	if err != nil {
+		t.Fatalf("Decode: %v", err)
+	}
+	if msgType != Completion {
+		t.Fatalf("msgType = %d, want %d", msgType, Completion)
+	}
+
+	var got CompletionMessage
+	if err := json.Unmarshal(payload, &got); err != nil {
+		t.Fatalf("Decode payload does not unmarshal into CompletionMessage: %v", err)
+	}
+	if got.InvocationID != "7" || got.Error != "" {
+		t.Fatalf("unexpected round-tripped message: %+v", got)
+	}
+
+	var result struct{ N int }
+	if err := json.Unmarshal(got.Result, &result); err != nil || result.N != 1 {
+		t.Fatalf("unexpected result: %s (err=%v)", got.Result, err)
+	}
+}
@@ -0,0 +1,123 @@
+package signalr
+
+import (
+	"errors"
+	"time"
+)
+
+// handleClose decides whether a server Close message should trigger
+// automatic reconnect or end the connection for good.
+func (c *Client) handleClose(cm CloseMessage) {
+	var cause error
+	if cm.Error != "" {
+		cause = errors.New(cm.Error)
+	}
+
+	if !cm.AllowReconnect {
+		c.mutex.Lock()
+		c.stopped = true
+		c.mutex.Unlock()
+		c.closeFinal(cause)
+		return
+	}
+
+	c.maybeReconnect(cause)
+}
+
+// maybeReconnect starts runReconnectLoop in the background unless the client
+// was stopped on purpose, a reconnect is already underway, or no
+// ReconnectPolicy was configured.
+func (c *Client) maybeReconnect(cause error) {
+	c.mutex.Lock()
+	if c.stopped || c.reconnecting {
+		c.mutex.Unlock()
+		return
+	}
+
+	if c.reconnectPolicy == nil {
+		c.mutex.Unlock()
+		c.closeFinal(cause)
+		return
+	}
+
+	c.reconnecting = true
+	c.mutex.Unlock()
+
+	go c.runReconnectLoop(cause)
+}
+
+// runReconnectLoop retries negotiate+connect+handshake using c.reconnectPolicy
+// to space out attempts, replaying any pending Invoke/Stream calls once the
+// connection is re-established, if WithReplayPendingOnReconnect was used.
+func (c *Client) runReconnectLoop(cause error) {
+	defer func() {
+		c.mutex.Lock()
+		c.reconnecting = false
+		c.mutex.Unlock()
+	}()
+
+	if c.onReconnecting != nil {
+		c.onReconnecting(cause)
+	}
+
+	c.mutex.Lock()
+	p := c.negotiation
+	c.mutex.Unlock()
+
+	for attempt := 1; ; attempt++ {
+		delay, ok := c.reconnectPolicy(attempt)
+		if !ok {
+			c.closeFinal(cause)
+			return
+		}
+
+		time.Sleep(delay)
+
+		c.mutex.Lock()
+		stopped := c.stopped
+		c.mutex.Unlock()
+		if stopped {
+			return
+		}
+
+		if err := c.Start(p); err != nil {
+			cause = err
+			continue
+		}
+
+		if c.replayPendingOnReconnect {
+			c.replayPending()
+		}
+
+		if c.onReconnected != nil {
+			c.onReconnected()
+		}
+
+		return
+	}
+}
+
+// replayPending re-sends the InvokeMessage/StreamInvocation of every call
+// still awaiting a Completion, so in-flight calls survive a reconnect. Only
+// called when WithReplayPendingOnReconnect is set: the client has no way to
+// know whether the original invocation already ran on the server before the
+// connection dropped, so this is an at-least-once replay, not exactly-once
+// - safe only for hub methods that tolerate being invoked twice.
+func (c *Client) replayPending() {
+	c.mutex.Lock()
+	msgs := make([]InvokeMessage, 0, len(c.pending))
+	for _, pc := range c.pending {
+		msgs = append(msgs, pc.msg)
+	}
+	c.mutex.Unlock()
+
+	for _, msg := range msgs {
+		_ = c.sendMessage(msg)
+	}
+}
+
+func (c *Client) closeFinal(cause error) {
+	if c.onClose != nil {
+		c.onClose(cause)
+	}
+}
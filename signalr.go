@@ -1,12 +1,14 @@
 package signalr
 
 import (
+	"context"
 	"encoding/json"
 	"fmt"
 	"io/ioutil"
 	"net/http"
 	"net/url"
 	"sync"
+	"sync/atomic"
 	"time"
 
 	"github.com/gorilla/websocket"
@@ -88,12 +90,31 @@ type CompletionMessage struct {
 	// Unique ID to represent the invocation
 	InvocationID string `json:"invocationId"`
 
-	// Result of the invocation
-	Result string `json:"result"`
+	// Result of the invocation, absent for void methods
+	Result json.RawMessage `json:"result,omitempty"`
 
 	Error string `json:"error"`
 }
 
+// StreamItemMessage ...
+type StreamItemMessage struct {
+	Type int `json:"type"`
+
+	// Unique ID of the StreamInvocation this item belongs to
+	InvocationID string `json:"invocationId"`
+
+	// Item is a single element of the streamed response
+	Item json.RawMessage `json:"item"`
+}
+
+// CancelInvocationMessage ...
+type CancelInvocationMessage struct {
+	Type int `json:"type"`
+
+	// Unique ID of the StreamInvocation to cancel
+	InvocationID string `json:"invocationId"`
+}
+
 // PingMessage ...
 type PingMessage struct {
 	Type int `json:"type"`
@@ -103,6 +124,11 @@ type PingMessage struct {
 type CloseMessage struct {
 	Type  int    `json:"type"`
 	Error string `json:"error"`
+
+	// AllowReconnect indicates the client should attempt to reconnect using
+	// its configured ReconnectPolicy rather than treat the connection as
+	// permanently closed.
+	AllowReconnect bool `json:"allowReconnect"`
 }
 
 // HandshakeRequestMessage ...
@@ -128,6 +154,10 @@ type NegotiationRequestPayload struct {
 
 	// Optional bearer  token for accessing the specified url
 	AccessToken string `json:"accessToken"`
+
+	// Protocol picks the Hub Protocol used for every message after the
+	// handshake. Defaults to JSONProtocol when left nil.
+	Protocol Protocol `json:"-"`
 }
 
 // NegotiationError ...
@@ -155,7 +185,48 @@ type Client struct {
 	Conn   *websocket.Conn
 	Params NegotiationResponse
 
+	protocol  Protocol
+	transport Transport
+
+	dialer     *websocket.Dialer
+	httpClient *http.Client
+	logger     Logger
+	handler    func(InvokeMessage)
+
+	// negotiation is the payload NewClient was constructed with, reused by
+	// Start when called without one and replayed by runReconnectLoop.
+	negotiation NegotiationRequestPayload
+
+	reconnectPolicy          func(attempt int) (time.Duration, bool)
+	replayPendingOnReconnect bool
+	onReconnecting           func(err error)
+	onReconnected            func()
+	onClose                  func(err error)
+
+	stopped      bool
+	reconnecting bool
+
+	pingPeriod time.Duration
+	pongWait   time.Duration
+	writeWait  time.Duration
+	pingStop   chan struct{}
+	lastActive atomic.Int64
+
+	compressionEnabled   bool
+	compressionLevel     int
+	compressionThreshold int
+
 	mutex *sync.Mutex
+
+	// writeMutex serializes every write to transport: gorilla/websocket
+	// (and the SSE/long-polling transports' underlying connections) permit
+	// only one writer at a time, but writeFrame, sendHandshake and the
+	// keep-alive ping ticker all write from different goroutines.
+	writeMutex sync.Mutex
+
+	// pending tracks in-flight Invoke/Stream calls by invocation ID
+	pending       map[string]*pendingCall
+	invocationSeq uint64
 }
 
 func init() {
@@ -165,30 +236,52 @@ func init() {
 // New ...
 func New() *Client {
 	return &Client{
-		Conn:   &websocket.Conn{},
-		Params: NegotiationResponse{},
-		mutex:  &sync.Mutex{},
+		Conn:       &websocket.Conn{},
+		Params:     NegotiationResponse{},
+		protocol:   &JSONProtocol{},
+		dialer:     websocket.DefaultDialer,
+		httpClient: http.DefaultClient,
+		pingPeriod: pingPeriod,
+		pongWait:   pongWait,
+		writeWait:  waitWait,
+		mutex:      &sync.Mutex{},
+		pending:    make(map[string]*pendingCall),
 	}
 }
 
+// NewClient constructs a Client configured with opts, ready to Start against
+// p. Unlike the package-level functions, a Client returned by NewClient is
+// independent of the default client and safe to use alongside any number of
+// other Clients.
+func NewClient(p NegotiationRequestPayload, opts ...Option) *Client {
+	c := New()
+	c.negotiation = p
+
+	for _, opt := range opts {
+		opt(c)
+	}
+
+	return c
+}
+
 // Start ...
 func Start(p NegotiationRequestPayload) error {
-	return signalrClient.start(p)
+	return signalrClient.Start(p)
 }
 
 // Stop disables socket connection
 func Stop() error {
-	return signalrClient.stop()
+	return signalrClient.Stop()
 }
 
 // Read retrieves response on the socket
 func Read(f func(d InvokeMessage)) (n int, p []byte, err error) {
-	return signalrClient.read(f)
+	return signalrClient.Read(f)
 }
 
 // Send writes data on the socket
 func Send(m []byte) error {
-	return signalrClient.send(m)
+	return signalrClient.Send(m)
 }
 
 // Conn returns Websocket connection
@@ -196,45 +289,106 @@ func Conn() *websocket.Conn {
 	return signalrClient.Conn
 }
 
-func (c *Client) start(p NegotiationRequestPayload) error {
+// Stream invokes a streaming method on the server. See Client.Stream.
+func Stream(target string, args ...interface{}) (*StreamHandle, error) {
+	return signalrClient.Stream(target, args...)
+}
+
+// Invoke calls target on the server and blocks for its result. See
+// Client.Invoke.
+func Invoke(ctx context.Context, target string, args ...interface{}) (json.RawMessage, error) {
+	return signalrClient.Invoke(ctx, target, args...)
+}
+
+// Start negotiates and opens a connection to the hub described by p,
+// remembering p for subsequent calls to Start made without one.
+func (c *Client) Start(p NegotiationRequestPayload) error {
 
 	c.mutex.Lock()
 	defer c.mutex.Unlock()
 
-	nRes, err := negotiate(p)
+	if p.URL == "" {
+		p = c.negotiation
+	}
+
+	if p.Protocol != nil {
+		c.protocol = p.Protocol
+	}
+
+	c.logf("signalr: negotiating with %s", p.URL)
+
+	resolved, nRes, err := c.negotiate(p)
 	if err != nil {
 		return err
 	}
 
+	// Remember the payload actually used (after following any negotiate
+	// redirects) so Start can be replayed by runReconnectLoop.
+	c.negotiation = resolved
+
 	c.Params = nRes
-	conn, err := connect(p, nRes)
+	transport, err := c.connect(resolved, nRes)
 	if err != nil {
 		return err
 	}
 
-	c.Conn = conn
+	c.transport = transport
 
+	// The handshake request/response pair is always exchanged as JSON,
+	// regardless of the protocol negotiated for the messages that follow.
 	var hsm HandshakeRequestMessage
-	hsm.Protocol = "json"
+	hsm.Protocol = c.protocol.Name()
 	hsm.Version = 1
 
-	data, err := json.Marshal(hsm)
+	data, err := (&JSONProtocol{}).Encode(hsm)
 	if err != nil {
 		return err
 	}
 
-	err = c.send(data)
+	err = c.sendHandshake(data)
 	if err != nil {
 		return err
 	}
 
+	c.startKeepAlive()
+
 	return nil
 
 }
 
-// This request is used to establish a connection betwen the client and the server
+// negotiate establishes a connection between the client and the server,
+// following any negotiate redirects (e.g. to Azure SignalR Service) up to
+// maxRedirects hops. It returns the payload the server ultimately accepted
+// the connection against, alongside its negotiation response.
+func (c *Client) negotiate(p NegotiationRequestPayload) (NegotiationRequestPayload, NegotiationResponse, error) {
+	seen := map[string]bool{p.URL: true}
+
+	for i := 0; i < maxRedirects; i++ {
+		response, err := c.negotiateOnce(p)
+		if err != nil {
+			return p, response, err
+		}
+
+		if response.URL == "" {
+			return p, response, nil
+		}
+
+		if seen[response.URL] {
+			return p, response, fmt.Errorf("signalr: negotiate redirect cycle detected at %s", response.URL)
+		}
+		seen[response.URL] = true
+
+		c.logf("signalr: negotiate redirected to %s", response.URL)
+		p.URL = response.URL
+		p.AccessToken = response.AccessToken
+	}
+
+	return p, NegotiationResponse{}, fmt.Errorf("signalr: exceeded %d negotiate redirects", maxRedirects)
+}
+
+// negotiateOnce performs a single negotiate request.
 // Connection type of the response is application/json
-func negotiate(p NegotiationRequestPayload) (NegotiationResponse, error) {
+func (c *Client) negotiateOnce(p NegotiationRequestPayload) (NegotiationResponse, error) {
 	var response NegotiationResponse
 	connectionURL, err := url.Parse(p.URL)
 	if err != nil {
@@ -252,8 +406,7 @@ func negotiate(p NegotiationRequestPayload) (NegotiationResponse, error) {
 		req.Header.Add("Authorization", fmt.Sprintf("Bearer %s", p.AccessToken))
 	}
 
-	client := &http.Client{}
-	res, err := client.Do(req)
+	res, err := c.httpClient.Do(req)
 	if err != nil {
 		return response, err
 	}
@@ -269,62 +422,286 @@ func negotiate(p NegotiationRequestPayload) (NegotiationResponse, error) {
 	}
 }
 
-func connect(p NegotiationRequestPayload, params NegotiationResponse) (*websocket.Conn, error) {
-	var urlParams = url.Values{}
+// connect picks a Transport from params.AvailableTransports, trying each in
+// transportPreference order and falling back to the next on dial failure.
+// When the server omits AvailableTransports, it dials WebSockets directly,
+// matching the client's original behavior.
+func (c *Client) connect(p NegotiationRequestPayload, params NegotiationResponse) (Transport, error) {
+	if len(params.AvailableTransports) == 0 {
+		return c.connectWebSocket(p, params)
+	}
+
+	var lastErr error
+	for _, name := range transportPreference {
+		if !hasTransport(params.AvailableTransports, name, c.protocol.FrameType()) {
+			continue
+		}
+
+		t, err := c.dialTransport(name, p, params)
+		if err != nil {
+			c.logf("signalr: %s transport failed: %v", name, err)
+			lastErr = err
+			continue
+		}
+
+		return t, nil
+	}
+
+	if lastErr == nil {
+		lastErr = fmt.Errorf("signalr: server offered no supported transport (got %v)", params.AvailableTransports)
+	}
+
+	return nil, lastErr
+}
+
+func (c *Client) dialTransport(name string, p NegotiationRequestPayload, params NegotiationResponse) (Transport, error) {
+	switch name {
+	case "WebSockets":
+		return c.connectWebSocket(p, params)
+	case "ServerSentEvents":
+		return c.connectSSE(p, params)
+	case "LongPolling":
+		return c.connectLongPolling(p, params)
+	default:
+		return nil, fmt.Errorf("signalr: unsupported transport %q", name)
+	}
+}
+
+// hasTransport reports whether available lists a transport called name that
+// also supports frameType (TextMessage or BinaryMessage), per its
+// TransportFormats. MessagePack, for instance, cannot be carried over a
+// transport that only advertises "Text".
+func hasTransport(available []AvailableTransport, name string, frameType int) bool {
+	wantFormat := "Text"
+	if frameType == websocket.BinaryMessage {
+		wantFormat = "Binary"
+	}
+
+	for _, at := range available {
+		if at.Transport != name {
+			continue
+		}
+
+		for _, f := range at.TransportFormats {
+			if f == wantFormat {
+				return true
+			}
+		}
+	}
+
+	return false
+}
 
+// buildConnectURL appends the connectionId query param used by every
+// transport. scheme, when non-empty, overrides the URL's scheme (e.g. "ws"
+// for the WebSocket transport); SSE and Long Polling reuse p.URL's scheme.
+func buildConnectURL(p NegotiationRequestPayload, params NegotiationResponse, scheme string) (string, error) {
 	connectionURL, err := url.Parse(p.URL)
 	if err != nil {
-		return nil, err
+		return "", err
 	}
 
+	urlParams := url.Values{}
 	urlParams.Set("id", params.ConnectionID)
 
-	connectionURL.Scheme = "ws"
+	if scheme != "" {
+		connectionURL.Scheme = scheme
+	}
 	connectionURL.RawQuery = fmt.Sprintf("%s&%s", urlParams.Encode(), connectionURL.RawQuery)
 
+	return connectionURL.String(), nil
+}
+
+func (c *Client) connectWebSocket(p NegotiationRequestPayload, params NegotiationResponse) (Transport, error) {
+	connectionURL, err := buildConnectURL(p, params, "ws")
+	if err != nil {
+		return nil, err
+	}
+
 	reqHeaders := http.Header{}
 	if p.AccessToken != "" {
 		reqHeaders.Add("Authorization", fmt.Sprintf("Bearer %s", p.AccessToken))
 	}
 
-	conn, _, err := websocket.DefaultDialer.Dial(connectionURL.String(), reqHeaders)
+	conn, _, err := c.dialer.Dial(connectionURL, reqHeaders)
 	if err != nil {
 		return nil, err
 	}
 
-	return conn, nil
+	if c.compressionEnabled {
+		conn.EnableWriteCompression(true)
+		conn.SetCompressionLevel(c.compressionLevel)
+	}
+
+	// Kept in sync for back-compat with code that reaches for Client.Conn
+	// directly, e.g. via the package-level Conn function.
+	c.Conn = conn
 
+	return &wsTransport{conn: conn, frameType: c.protocol.FrameType()}, nil
 }
 
-func (c *Client) stop() error {
+func (c *Client) connectSSE(p NegotiationRequestPayload, params NegotiationResponse) (Transport, error) {
+	connectURL, err := buildConnectURL(p, params, "")
+	if err != nil {
+		return nil, err
+	}
+
+	return newSSETransport(c.httpClient, connectURL, p.AccessToken)
+}
+
+func (c *Client) connectLongPolling(p NegotiationRequestPayload, params NegotiationResponse) (Transport, error) {
+	connectURL, err := buildConnectURL(p, params, "")
+	if err != nil {
+		return nil, err
+	}
+
+	return newLPTransport(c.httpClient, connectURL, p.AccessToken), nil
+}
+
+// Stop disables socket connection. Once Stop has been called the client will
+// not attempt to automatically reconnect.
+func (c *Client) Stop() error {
 	c.mutex.Lock()
-	defer c.mutex.Unlock()
-	return c.Conn.Close()
+	wasStopped := c.stopped
+	c.stopped = true
+	if c.pingStop != nil {
+		close(c.pingStop)
+		c.pingStop = nil
+	}
+	t := c.transport
+	c.mutex.Unlock()
+
+	if !wasStopped {
+		defer c.closeFinal(nil)
+	}
+
+	if t != nil {
+		return t.Close()
+	}
+
+	// Stop was called before Start ever set up a transport (e.g. right
+	// after NewClient, or following a failed Start/negotiate): there is
+	// nothing to close. c.Conn is a non-nil *websocket.Conn{} zero value
+	// in that case, and calling Close on it panics.
+	return nil
 }
 
-func (c *Client) send(m []byte) error {
-	message := mFormat.write(string(m))
-	return c.Conn.WriteMessage(websocket.TextMessage, []byte(message))
+// Send writes data on the socket
+func (c *Client) Send(m []byte) error {
+	data, err := c.protocol.Encode(m)
+	if err != nil {
+		return err
+	}
+
+	return c.writeFrame(data)
+}
+
+// writeFrame writes a single frame through the client's Transport, toggling
+// per-message write compression based on compressionThreshold when
+// WithCompression was used to configure the client, and bounding a WebSocket
+// write by writeWait. writeMutex is held for
+// the duration of the write so it cannot interleave with a concurrent
+// sendHandshake or keep-alive ping.
+func (c *Client) writeFrame(data []byte) error {
+	c.writeMutex.Lock()
+	defer c.writeMutex.Unlock()
+
+	if ws, ok := c.transport.(*wsTransport); ok {
+		ws.conn.SetWriteDeadline(time.Now().Add(c.writeWait))
+		if c.compressionEnabled {
+			ws.conn.EnableWriteCompression(len(data) >= c.compressionThreshold)
+		}
+	}
+
+	return c.transport.Send(data)
+}
+
+// sendHandshake writes the handshake payload, which is always sent as a
+// websocket text frame regardless of the negotiated Protocol.
+func (c *Client) sendHandshake(data []byte) error {
+	c.writeMutex.Lock()
+	defer c.writeMutex.Unlock()
+
+	if ws, ok := c.transport.(*wsTransport); ok {
+		if c.compressionEnabled {
+			ws.conn.EnableWriteCompression(len(data) >= c.compressionThreshold)
+		}
+		return ws.sendText(data)
+	}
+
+	return c.transport.Send(data)
+}
+
+// logf writes to the Client's Logger, if one was configured via WithLogger.
+func (c *Client) logf(format string, v ...interface{}) {
+	if c.logger != nil {
+		c.logger.Printf(format, v...)
+	}
 }
 
-func (c *Client) read(f func(d InvokeMessage)) (n int, p []byte, err error) {
-	_, p, err = c.Conn.ReadMessage()
+// sendMessage encodes and writes an arbitrary Hub Protocol message, such as
+// an InvokeMessage or CancelInvocationMessage.
+func (c *Client) sendMessage(msg interface{}) error {
+	data, err := c.protocol.Encode(msg)
 	if err != nil {
+		return err
+	}
+
+	return c.writeFrame(data)
+}
+
+// Read retrieves the next message on the socket. f is invoked for every
+// Invocation message; when f is nil the handler registered via WithHandler,
+// if any, is used instead.
+func (c *Client) Read(f func(d InvokeMessage)) (n int, p []byte, err error) {
+	frame, err := c.transport.Receive()
+	if err != nil {
+		c.maybeReconnect(err)
 		return
 	}
 
-	formatedMessage := mFormat.parse(p)
+	n, p, err = c.protocol.Decode(frame)
+	if err != nil {
+		return
+	}
+
+	c.touch()
+
+	// Protocol.Decode always normalizes payload to the same JSON
+	// representation regardless of wire format, so json.Unmarshal below (and
+	// in dispatchStreamItem/dispatchCompletion) works the same for JSON and
+	// MessagePack.
 	var data InvokeMessage
-	_ = json.Unmarshal(formatedMessage, &data)
+	_ = json.Unmarshal(p, &data)
+	data.Type = n
 
-	n = data.Type
+	handler := f
+	if handler == nil {
+		handler = c.handler
+	}
 
 	switch n {
 	case Invocation:
-		go f(data)
+		if handler != nil {
+			go handler(data)
+		}
+		break
+	case StreamItem:
+		c.dispatchStreamItem(p)
 		break
 	case Completion:
-		// TODO add handler
+		c.dispatchCompletion(p)
+		break
+	case Ping:
+		if ws, ok := c.transport.(*wsTransport); ok {
+			ws.conn.SetReadDeadline(time.Now().Add(c.pongWait))
+		}
+		break
+	case Close:
+		var cm CloseMessage
+		if json.Unmarshal(p, &cm) == nil {
+			c.handleClose(cm)
+		}
 		break
 	}
 
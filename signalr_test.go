@@ -1,87 +1,273 @@
 package signalr
 
 import (
+	"context"
 	"encoding/json"
-	"errors"
-	"fmt"
-	"strings"
-	"sync"
+	"net/http"
+	"net/http/httptest"
 	"testing"
+	"time"
+
+	"github.com/gorilla/websocket"
 )
 
-var ngp NegotiationRequestPayload
+// newTestHub starts an httptest.Server that serves a negotiate response
+// advertising no AvailableTransports - which makes Client.connect dial
+// WebSockets directly - and upgrades the connect request to a WebSocket,
+// handing the resulting connection to handle. handle must eventually return
+// (or the test will hang); the connection is closed when it does.
+func newTestHub(t *testing.T, handle func(conn *websocket.Conn)) *httptest.Server {
+	t.Helper()
+
+	upgrader := websocket.Upgrader{}
+
+	mux := http.NewServeMux()
+	mux.HandleFunc("/negotiate", func(w http.ResponseWriter, r *http.Request) {
+		_ = json.NewEncoder(w).Encode(NegotiationResponse{ConnectionID: "test-conn"})
+	})
+	mux.HandleFunc("/", func(w http.ResponseWriter, r *http.Request) {
+		conn, err := upgrader.Upgrade(w, r, nil)
+		if err != nil {
+			t.Errorf("upgrade: %v", err)
+			return
+		}
+		defer conn.Close()
+
+		handle(conn)
+	})
+
+	return httptest.NewServer(mux)
+}
 
-func TestSignalrConnection(t *testing.T) {
-	ngp.URL = "YOUR SOCKET URL TO SIGNALR SERVER"
-	err := Start(ngp)
+// writeTestFrame writes a JSON Hub Protocol frame: the marshaled msg
+// followed by the record separator.
+func writeTestFrame(conn *websocket.Conn, msg interface{}) error {
+	data, err := json.Marshal(msg)
 	if err != nil {
-		t.Error(err)
+		return err
 	}
 
-	errchan := make(chan error, 1)
-	done := make(chan bool)
+	return conn.WriteMessage(websocket.TextMessage, append(data, recordSeparatorCode))
+}
 
-	var wg sync.WaitGroup
+func TestClientStartHandshake(t *testing.T) {
+	done := make(chan struct{})
+	server := newTestHub(t, func(conn *websocket.Conn) {
+		if _, _, err := conn.ReadMessage(); err != nil {
+			t.Errorf("read handshake request: %v", err)
+			return
+		}
 
-	wg.Add(1)
+		if err := writeTestFrame(conn, HandshakeResponseMessage{}); err != nil {
+			t.Errorf("write handshake response: %v", err)
+			return
+		}
 
-	go func() {
-		defer wg.Done()
+		<-done
+	})
+	defer server.Close()
 
-		for {
-			t, p, err := Read(func(d InvokeMessage) { fmt.Println("Received Message: ", d) })
+	c := NewClient(NegotiationRequestPayload{URL: server.URL})
+	if err := c.Start(c.negotiation); err != nil {
+		t.Fatalf("Start: %v", err)
+	}
+	defer c.Stop()
 
-			if err != nil {
-				errchan <- err
-				break
-			}
+	_, payload, err := c.Read(nil)
+	close(done)
+	if err != nil {
+		t.Fatalf("Read: %v", err)
+	}
 
-			// handshake successful
-			if strings.Contains(string(p), "{}") {
-				errchan <- nil
-				signalrClient.stop()
-				return
-			} else if !strings.Contains(string(p), "{}") {
-				errchan <- errors.New("Handshake failed")
-				return
-			}
+	var hsr HandshakeResponseMessage
+	if err := json.Unmarshal(payload, &hsr); err != nil {
+		t.Fatalf("handshake response did not unmarshal: %s (%v)", payload, err)
+	}
+	if hsr.Error != "" {
+		t.Fatalf("unexpected handshake error: %s", hsr.Error)
+	}
+}
 
-			if t == Close {
-				done <- true
-				return
-			}
+func TestClientInvoke(t *testing.T) {
+	done := make(chan struct{})
+	server := newTestHub(t, func(conn *websocket.Conn) {
+		if _, _, err := conn.ReadMessage(); err != nil {
+			t.Errorf("read handshake request: %v", err)
+			return
+		}
+		if err := writeTestFrame(conn, HandshakeResponseMessage{}); err != nil {
+			t.Errorf("write handshake response: %v", err)
+			return
+		}
+
+		_, frame, err := conn.ReadMessage()
+		if err != nil {
+			t.Errorf("read invocation: %v", err)
+			return
+		}
 
+		var msg InvokeMessage
+		if err := json.Unmarshal(frame[:len(frame)-1], &msg); err != nil {
+			t.Errorf("decode invocation: %v", err)
+			return
 		}
 
+		err = writeTestFrame(conn, CompletionMessage{
+			Type:         Completion,
+			InvocationID: msg.InvocationID,
+			Result:       json.RawMessage(`"ok"`),
+		})
+		if err != nil {
+			t.Errorf("write completion: %v", err)
+		}
+
+		<-done
+	})
+	defer server.Close()
+
+	c := NewClient(NegotiationRequestPayload{URL: server.URL})
+	if err := c.Start(c.negotiation); err != nil {
+		t.Fatalf("Start: %v", err)
+	}
+	defer c.Stop()
+
+	go func() {
+		c.Read(nil) // handshake response
+		c.Read(nil) // dispatches the Completion above
 	}()
 
-	wg.Wait()
-	err = <-errchan
+	ctx, cancel := context.WithTimeout(context.Background(), 2*time.Second)
+	defer cancel()
+
+	result, err := c.Invoke(ctx, "Echo", "hi")
+	close(done)
 	if err != nil {
+		t.Fatalf("Invoke: %v", err)
+	}
+	if string(result) != `"ok"` {
+		t.Fatalf("unexpected result: %s", result)
+	}
+}
+
+func TestStreamHandleCancelClosesItems(t *testing.T) {
+	c := New()
+
+	pc := &pendingCall{items: make(chan json.RawMessage), done: make(chan struct{}), cancelled: make(chan struct{})}
+	c.pending["1"] = pc
 
-		t.Error(err)
+	// Cancel writes a CancelInvocation frame; give it a no-op transport so
+	// it doesn't need a live connection.
+	c.transport = discardTransport{}
+
+	h := &StreamHandle{id: "1", client: c, pc: pc}
+	if err := h.Cancel(); err != nil {
+		t.Fatalf("Cancel: %v", err)
 	}
 
+	select {
+	case _, ok := <-h.Items():
+		if ok {
+			t.Fatal("Items channel should be closed, got a value")
+		}
+	case <-time.After(time.Second):
+		t.Fatal("Items channel was not closed by Cancel")
+	}
 }
 
-func TestSignalrSendInvocation(t *testing.T) {
-	var getbroadcastMsg InvokeMessage
-	getbroadcastMsg.InvocationID = "1"
-	getbroadcastMsg.Target = "GetBroadCastMessage"
-	getbroadcastMsg.Arguments = []interface{}{"walugembeamos@gmail.com"}
+// TestStreamHandleCancelRacesDispatch exercises Cancel racing against
+// concurrent StreamItem dispatch. Before Cancel deferred closing Items to an
+// in-flight dispatchStreamItem send (pendingCall.sending/closeAfterSend),
+// this reproduced "send on closed channel" panics under -race.
+func TestStreamHandleCancelRacesDispatch(t *testing.T) {
+	for i := 0; i < 100; i++ {
+		c := New()
+		c.transport = discardTransport{}
 
-	b, err := json.Marshal(getbroadcastMsg)
-	if err != nil {
-		t.Error(err)
+		pc := &pendingCall{items: make(chan json.RawMessage), done: make(chan struct{}), cancelled: make(chan struct{})}
+		c.pending["1"] = pc
+		h := &StreamHandle{id: "1", client: c, pc: pc}
+
+		go func() {
+			for range h.Items() {
+			}
+		}()
+
+		payload, err := json.Marshal(StreamItemMessage{Type: StreamItem, InvocationID: "1", Item: json.RawMessage(`1`)})
+		if err != nil {
+			t.Fatalf("marshal: %v", err)
+		}
+
+		done := make(chan struct{})
+		go func() {
+			defer close(done)
+			c.dispatchStreamItem(payload)
+		}()
+
+		if err := h.Cancel(); err != nil {
+			t.Fatalf("Cancel: %v", err)
+		}
+		<-done
 	}
+}
 
-	err = Conn().WriteMessage(Invocation, b)
+// TestStreamHandleCancelWhileConsumerBusy reproduces a deadlock a reviewer
+// found in an earlier version of dispatchStreamItem: it held c.mutex for the
+// whole blocking send to pc.items, so Cancel - called by the same consumer
+// goroutine that is not currently receiving - could never acquire c.mutex to
+// proceed, and the in-flight send could never complete either. Both sides
+// must make progress even though the consumer stops draining Items().
+func TestStreamHandleCancelWhileConsumerBusy(t *testing.T) {
+	c := New()
+	c.transport = discardTransport{}
+
+	pc := &pendingCall{items: make(chan json.RawMessage), done: make(chan struct{}), cancelled: make(chan struct{})}
+	c.pending["1"] = pc
+	h := &StreamHandle{id: "1", client: c, pc: pc}
+
+	item1, err := json.Marshal(StreamItemMessage{Type: StreamItem, InvocationID: "1", Item: json.RawMessage(`1`)})
+	if err != nil {
+		t.Fatalf("marshal: %v", err)
+	}
+	item2, err := json.Marshal(StreamItemMessage{Type: StreamItem, InvocationID: "1", Item: json.RawMessage(`2`)})
 	if err != nil {
-		t.Error(err)
+		t.Fatalf("marshal: %v", err)
 	}
 
-}
+	go c.dispatchStreamItem(item1)
+	<-h.Items() // consumer takes item1, then gets busy instead of receiving again
+
+	dispatchDone := make(chan struct{})
+	go func() {
+		defer close(dispatchDone)
+		c.dispatchStreamItem(item2) // blocks: no one is receiving
+	}()
+	time.Sleep(20 * time.Millisecond) // let the item2 send block
 
-func TestSignalrReceiveInvocation(t *testing.T) {
+	cancelDone := make(chan struct{})
+	go func() {
+		defer close(cancelDone)
+		if err := h.Cancel(); err != nil {
+			t.Errorf("Cancel: %v", err)
+		}
+	}()
+
+	select {
+	case <-cancelDone:
+	case <-time.After(time.Second):
+		t.Fatal("Cancel did not return - deadlocked on c.mutex held by the blocked item2 send")
+	}
 
+	select {
+	case <-dispatchDone:
+	case <-time.After(time.Second):
+		t.Fatal("dispatchStreamItem for item2 never returned after Cancel")
+	}
 }
+
+// discardTransport is a no-op Transport used to unit test client behavior
+// that doesn't need a real connection.
+type discardTransport struct{}
+
+func (discardTransport) Send([]byte) error        { return nil }
+func (discardTransport) Receive() ([]byte, error) { return nil, nil }
+func (discardTransport) Close() error             { return nil }
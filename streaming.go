@@ -0,0 +1,244 @@
+package signalr
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"strconv"
+	"sync/atomic"
+)
+
+// pendingCall tracks a single Invoke or Stream call awaiting a Completion
+// message, keyed by InvocationID in Client.pending.
+type pendingCall struct {
+	// items receives StreamItem payloads; nil for a non-streaming Invoke.
+	items chan json.RawMessage
+
+	// done is closed once the matching Completion message arrives.
+	done chan struct{}
+
+	// cancelled is closed by StreamHandle.Cancel to unblock a dispatchStreamItem
+	// call that is currently blocked sending to items, without touching items
+	// itself. nil for a non-streaming Invoke.
+	cancelled chan struct{}
+
+	// sending is true while dispatchStreamItem has a send to items in
+	// flight. Guarded by Client.mutex.
+	sending bool
+
+	// closeAfterSend tells dispatchStreamItem to close items/done itself once
+	// its in-flight send returns, because Cancel ran while sending was true
+	// and deferred the close rather than racing it. Guarded by Client.mutex.
+	closeAfterSend bool
+
+	// msg is the original InvokeMessage, kept so it can be replayed by
+	// runReconnectLoop after an automatic reconnect.
+	msg InvokeMessage
+
+	result json.RawMessage
+	err    error
+}
+
+// StreamHandle represents an in-flight StreamInvocation.
+type StreamHandle struct {
+	id     string
+	client *Client
+	pc     *pendingCall
+}
+
+// Items returns the channel of streamed results. It is closed when the
+// server sends the Completion message that ends the stream.
+func (h *StreamHandle) Items() <-chan json.RawMessage {
+	return h.pc.items
+}
+
+// Err returns the error, if any, that ended the stream. It is only valid to
+// call once the channel returned by Items has been drained and closed.
+func (h *StreamHandle) Err() error {
+	return h.pc.err
+}
+
+// Cancel sends a CancelInvocation message, telling the server to stop the
+// stream, and closes the channel returned by Items so a caller ranging over
+// it unblocks immediately rather than waiting for a Completion message the
+// server may never send. It does not wait for the server to acknowledge the
+// cancellation.
+//
+// If a dispatchStreamItem call is in the middle of delivering an item to the
+// same pendingCall, Cancel must not close items itself - that would race the
+// in-flight send. Instead it signals pc.cancelled, which unblocks that send,
+// and lets dispatchStreamItem do the close once it returns. Either way Cancel
+// itself never blocks waiting for that to happen.
+func (h *StreamHandle) Cancel() error {
+	h.client.mutex.Lock()
+	pc, ok := h.client.pending[h.id]
+	delete(h.client.pending, h.id)
+
+	var needSignal bool
+	if ok {
+		if pc.sending {
+			pc.closeAfterSend = true
+			needSignal = true
+		} else {
+			close(pc.items)
+			close(pc.done)
+		}
+	}
+	h.client.mutex.Unlock()
+
+	if needSignal {
+		close(pc.cancelled)
+	}
+
+	return h.client.sendMessage(CancelInvocationMessage{
+		Type:         CancelInvocation,
+		InvocationID: h.id,
+	})
+}
+
+// nextInvocationID returns a unique, per-client invocation ID suitable for
+// InvokeMessage.InvocationID.
+func (c *Client) nextInvocationID() string {
+	return strconv.FormatUint(atomic.AddUint64(&c.invocationSeq, 1), 10)
+}
+
+// Stream invokes a streaming method on the server and returns a StreamHandle
+// that yields one item per StreamItem message until the server sends the
+// Completion message that ends the stream.
+func (c *Client) Stream(target string, args ...interface{}) (*StreamHandle, error) {
+	id := c.nextInvocationID()
+
+	msg := InvokeMessage{
+		Type:         StreamInvocation,
+		InvocationID: id,
+		Target:       target,
+		Arguments:    args,
+	}
+
+	pc := &pendingCall{
+		items:     make(chan json.RawMessage),
+		done:      make(chan struct{}),
+		cancelled: make(chan struct{}),
+		msg:       msg,
+	}
+
+	c.mutex.Lock()
+	c.pending[id] = pc
+	c.mutex.Unlock()
+
+	if err := c.sendMessage(msg); err != nil {
+		c.mutex.Lock()
+		delete(c.pending, id)
+		c.mutex.Unlock()
+		return nil, err
+	}
+
+	return &StreamHandle{id: id, client: c, pc: pc}, nil
+}
+
+// Invoke calls target on the server and blocks until the matching
+// Completion message arrives, ctx is done, or the invocation fails to send.
+func (c *Client) Invoke(ctx context.Context, target string, args ...interface{}) (json.RawMessage, error) {
+	id := c.nextInvocationID()
+
+	msg := InvokeMessage{
+		Type:         Invocation,
+		InvocationID: id,
+		Target:       target,
+		Arguments:    args,
+	}
+
+	pc := &pendingCall{done: make(chan struct{}), msg: msg}
+
+	c.mutex.Lock()
+	c.pending[id] = pc
+	c.mutex.Unlock()
+
+	if err := c.sendMessage(msg); err != nil {
+		c.mutex.Lock()
+		delete(c.pending, id)
+		c.mutex.Unlock()
+		return nil, err
+	}
+
+	select {
+	case <-ctx.Done():
+		c.mutex.Lock()
+		delete(c.pending, id)
+		c.mutex.Unlock()
+		return nil, ctx.Err()
+	case <-pc.done:
+		return pc.result, pc.err
+	}
+}
+
+// dispatchStreamItem routes a StreamItem message to the channel of its
+// matching pendingCall, if any is still tracked.
+//
+// The send to pc.items can block on a slow Items() consumer, so it must not
+// happen with c.mutex held - that would stall every other Invoke/Stream/
+// Cancel call for as long as the consumer takes, and deadlock entirely if
+// that same consumer is what eventually calls Cancel. So only the lookup and
+// the "a send is in flight" bookkeeping happen under the lock; the send
+// itself races pc.cancelled instead of pc.items, and pc.items is only ever
+// closed by whichever goroutine is not in the middle of sending to it - this
+// one, once its send returns, if Cancel asked it to via closeAfterSend.
+func (c *Client) dispatchStreamItem(payload []byte) {
+	var msg StreamItemMessage
+	if err := json.Unmarshal(payload, &msg); err != nil {
+		return
+	}
+
+	c.mutex.Lock()
+	pc := c.pending[msg.InvocationID]
+	if pc == nil || pc.items == nil {
+		c.mutex.Unlock()
+		return
+	}
+	pc.sending = true
+	c.mutex.Unlock()
+
+	select {
+	case pc.items <- msg.Item:
+	case <-pc.cancelled:
+	}
+
+	c.mutex.Lock()
+	pc.sending = false
+	closeNow := pc.closeAfterSend
+	c.mutex.Unlock()
+
+	if closeNow {
+		close(pc.items)
+		close(pc.done)
+	}
+}
+
+// dispatchCompletion resolves the pendingCall matching a Completion message,
+// closing its items channel (if streaming) and its done channel.
+func (c *Client) dispatchCompletion(payload []byte) {
+	var msg CompletionMessage
+	if err := json.Unmarshal(payload, &msg); err != nil {
+		return
+	}
+
+	c.mutex.Lock()
+	pc := c.pending[msg.InvocationID]
+	delete(c.pending, msg.InvocationID)
+	c.mutex.Unlock()
+
+	if pc == nil {
+		return
+	}
+
+	if msg.Error != "" {
+		pc.err = errors.New(msg.Error)
+	} else {
+		pc.result = msg.Result
+	}
+
+	if pc.items != nil {
+		close(pc.items)
+	}
+	close(pc.done)
+}
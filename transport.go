@@ -0,0 +1,50 @@
+package signalr
+
+import "github.com/gorilla/websocket"
+
+// Transport abstracts the underlying connection used to exchange frames
+// with the hub, so Client can fall back from WebSockets to Server-Sent
+// Events or Long Polling when a transport is unavailable or fails to dial.
+type Transport interface {
+	// Send writes a single frame to the server.
+	Send(data []byte) error
+
+	// Receive blocks until the next frame from the server is available.
+	Receive() (data []byte, err error)
+
+	// Close releases the transport's underlying resources.
+	Close() error
+}
+
+// transportPreference lists the transports Client.connect tries, in order,
+// mirroring the official SignalR client's negotiation preference.
+var transportPreference = []string{"WebSockets", "ServerSentEvents", "LongPolling"}
+
+// wsTransport implements Transport over a *websocket.Conn.
+type wsTransport struct {
+	conn      *websocket.Conn
+	frameType int
+}
+
+// Send ...
+func (t *wsTransport) Send(data []byte) error {
+	return t.conn.WriteMessage(t.frameType, data)
+}
+
+// sendText always writes data as a text frame, regardless of frameType,
+// used for the handshake which is exchanged as JSON even when the
+// negotiated Protocol is MessagePack.
+func (t *wsTransport) sendText(data []byte) error {
+	return t.conn.WriteMessage(websocket.TextMessage, data)
+}
+
+// Receive ...
+func (t *wsTransport) Receive() ([]byte, error) {
+	_, data, err := t.conn.ReadMessage()
+	return data, err
+}
+
+// Close ...
+func (t *wsTransport) Close() error {
+	return t.conn.Close()
+}
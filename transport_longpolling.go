@@ -0,0 +1,135 @@
+package signalr
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"sync"
+)
+
+// lpTransport implements Transport over SignalR's long polling fallback:
+// Receive repeats a GET against the connect URL until the server has a
+// frame (or the poll itself times out and is simply retried), and Send
+// posts a frame to the same URL.
+type lpTransport struct {
+	client      *http.Client
+	connectURL  string
+	accessToken string
+
+	mu     sync.Mutex
+	closed bool
+	cancel context.CancelFunc
+}
+
+func newLPTransport(hc *http.Client, connectURL, accessToken string) *lpTransport {
+	return &lpTransport{client: hc, connectURL: connectURL, accessToken: accessToken}
+}
+
+func (t *lpTransport) authorize(req *http.Request) {
+	if t.accessToken != "" {
+		req.Header.Set("Authorization", fmt.Sprintf("Bearer %s", t.accessToken))
+	}
+}
+
+// Receive polls the connect URL, skipping empty responses, which the server
+// uses to signal "no frame yet" rather than leaving the request hanging
+// forever.
+func (t *lpTransport) Receive() ([]byte, error) {
+	for {
+		body, status, err := t.poll()
+		if err != nil {
+			return nil, err
+		}
+
+		if status == http.StatusNoContent || len(body) == 0 {
+			continue
+		}
+
+		if status >= 300 {
+			return nil, fmt.Errorf("signalr: long polling GET failed with status %d", status)
+		}
+
+		return body, nil
+	}
+}
+
+// poll performs a single GET against the connect URL in a cancellable
+// context, storing its cancel func so Close can unblock this call promptly
+// rather than leaving it to hang until the server's own poll timeout.
+func (t *lpTransport) poll() (body []byte, status int, err error) {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	t.mu.Lock()
+	if t.closed {
+		t.mu.Unlock()
+		return nil, 0, errors.New("signalr: long polling transport closed")
+	}
+	t.cancel = cancel
+	t.mu.Unlock()
+
+	req, err := http.NewRequestWithContext(ctx, "GET", t.connectURL, nil)
+	if err != nil {
+		return nil, 0, err
+	}
+	t.authorize(req)
+
+	res, err := t.client.Do(req)
+	if err != nil {
+		if ctx.Err() != nil {
+			return nil, 0, errors.New("signalr: long polling transport closed")
+		}
+		return nil, 0, err
+	}
+	defer res.Body.Close()
+
+	body, err = ioutil.ReadAll(res.Body)
+	if err != nil {
+		if ctx.Err() != nil {
+			return nil, 0, errors.New("signalr: long polling transport closed")
+		}
+		return nil, 0, err
+	}
+
+	return body, res.StatusCode, nil
+}
+
+// Send posts a frame to the connect URL.
+func (t *lpTransport) Send(data []byte) error {
+	req, err := http.NewRequest("POST", t.connectURL, bytes.NewReader(data))
+	if err != nil {
+		return err
+	}
+	t.authorize(req)
+
+	res, err := t.client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer res.Body.Close()
+
+	if res.StatusCode >= 300 {
+		return fmt.Errorf("signalr: long polling POST failed with status %d", res.StatusCode)
+	}
+
+	return nil
+}
+
+// Close marks the transport closed and cancels any in-flight poll, so
+// Receive returns promptly instead of blocking until the server's poll
+// timeout.
+func (t *lpTransport) Close() error {
+	t.mu.Lock()
+	t.closed = true
+	cancel := t.cancel
+	t.mu.Unlock()
+
+	if cancel != nil {
+		cancel()
+	}
+
+	return nil
+}
@@ -0,0 +1,107 @@
+package signalr
+
+import (
+	"bufio"
+	"bytes"
+	"fmt"
+	"io"
+	"net/http"
+	"strings"
+)
+
+// sseTransport implements Transport over Server-Sent Events: frames are
+// received as "data:" lines on a long-lived GET request, and sent via a
+// companion POST to the same connect URL, as the official SignalR clients do
+// for browsers that can't use WebSockets.
+type sseTransport struct {
+	client      *http.Client
+	connectURL  string
+	accessToken string
+
+	body   io.ReadCloser
+	reader *bufio.Reader
+}
+
+func newSSETransport(hc *http.Client, connectURL, accessToken string) (*sseTransport, error) {
+	t := &sseTransport{client: hc, connectURL: connectURL, accessToken: accessToken}
+
+	req, err := http.NewRequest("GET", connectURL, nil)
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Set("Accept", "text/event-stream")
+	t.authorize(req)
+
+	res, err := hc.Do(req)
+	if err != nil {
+		return nil, err
+	}
+
+	if res.StatusCode != http.StatusOK {
+		res.Body.Close()
+		return nil, fmt.Errorf("signalr: sse connect failed with status %d", res.StatusCode)
+	}
+
+	t.body = res.Body
+	t.reader = bufio.NewReader(res.Body)
+
+	return t, nil
+}
+
+func (t *sseTransport) authorize(req *http.Request) {
+	if t.accessToken != "" {
+		req.Header.Set("Authorization", fmt.Sprintf("Bearer %s", t.accessToken))
+	}
+}
+
+// Send posts a frame to the connect URL, the SSE transport's companion send
+// endpoint.
+func (t *sseTransport) Send(data []byte) error {
+	req, err := http.NewRequest("POST", t.connectURL, bytes.NewReader(data))
+	if err != nil {
+		return err
+	}
+	t.authorize(req)
+
+	res, err := t.client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer res.Body.Close()
+
+	if res.StatusCode >= 300 {
+		return fmt.Errorf("signalr: sse send failed with status %d", res.StatusCode)
+	}
+
+	return nil
+}
+
+// Receive reads up to the next "data:" event and returns its payload, one
+// SignalR frame per event as the Hub Protocol's SSE binding requires.
+func (t *sseTransport) Receive() ([]byte, error) {
+	for {
+		line, err := t.reader.ReadString('\n')
+		if err != nil {
+			return nil, err
+		}
+
+		line = strings.TrimRight(line, "\r\n")
+		if !strings.HasPrefix(line, "data:") {
+			continue
+		}
+
+		data := strings.TrimPrefix(line, "data:")
+		data = strings.TrimPrefix(data, " ")
+
+		return []byte(data), nil
+	}
+}
+
+// Close ...
+func (t *sseTransport) Close() error {
+	if t.body == nil {
+		return nil
+	}
+
+	return t.body.Close()
+}